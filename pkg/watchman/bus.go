@@ -0,0 +1,276 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import "sync"
+
+// SubID identifies a subscriber registered via Watchman.Subscribe.
+type SubID uint64
+
+// SlowSubscriberPolicy controls what happens when a subscriber isn't
+// draining its channels fast enough to keep up with the poller.
+type SlowSubscriberPolicy int
+
+const (
+	// Block makes the poller wait for the subscriber to catch up. This is
+	// the default and matches the behaviour of the original single-channel
+	// Watchman.
+	Block SlowSubscriberPolicy = iota
+	// DropOldest discards the oldest buffered item to make room for the
+	// newest one, so a slow subscriber sees a partial but recent view. The
+	// per-subscriber buffer is forced to at least 1 under this policy even
+	// if WithSubscriberBuffer was left at its 0 default, since there's
+	// nothing to drop from an unbuffered channel.
+	DropOldest
+	// Disconnect unsubscribes a subscriber the moment it falls behind,
+	// closing its channels.
+	Disconnect
+)
+
+// subscriber holds one Subscribe caller's channels plus the state needed to
+// tear them down safely while the poller may be mid-send to them.
+//
+// closing is closed exactly once (via closeOnce) to interrupt a send that's
+// currently blocked in emitEvent/emitErr, without requiring the sender to
+// give up mu first. mu then serializes the actual close of events/errs
+// against any send, so a send is never attempted on a channel that's
+// already closed and a channel is never closed while a send to it is still
+// in flight; closed records that events/errs have been closed so neither
+// happens twice.
+type subscriber struct {
+	id      SubID
+	events  chan Event
+	errs    chan error
+	closing chan struct{}
+
+	closeOnce sync.Once
+	mu        sync.Mutex
+	closed    bool
+}
+
+// signalClosing interrupts any send currently blocked on sub.events/errs. It
+// doesn't itself touch those channels, so it's always safe to call without
+// holding sub.mu.
+func (sub *subscriber) signalClosing() {
+	sub.closeOnce.Do(func() {
+		close(sub.closing)
+	})
+}
+
+// closeChannelsLocked closes sub.events and sub.errs. The caller must hold
+// sub.mu, which guarantees no send to either channel is in flight.
+func (sub *subscriber) closeChannelsLocked() {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.events)
+	close(sub.errs)
+}
+
+// WithSubscriberBuffer sets the per-subscriber channel buffer size used by
+// Subscribe. The default is 0 (unbuffered). Ignored in favour of a minimum
+// of 1 for subscribers registered while the DropOldest policy is in effect.
+func WithSubscriberBuffer(n int) NewOpt {
+	return func(w *Watchman) error {
+		w.subscriberBuffer = n
+		return nil
+	}
+}
+
+// WithSlowSubscriberPolicy sets how the poller treats a subscriber that
+// isn't keeping up. The default is Block.
+func WithSlowSubscriberPolicy(policy SlowSubscriberPolicy) NewOpt {
+	return func(w *Watchman) error {
+		w.slowSubscriberPolicy = policy
+		return nil
+	}
+}
+
+// Subscribe registers a new subscriber and returns its id along with its
+// own event and error channels. Every subscriber receives every Event and
+// error on its own channels. Under DropOldest or Disconnect one slow
+// subscriber cannot hold up the others; under the default Block policy the
+// poller sends to subscribers one at a time and waits on each, so a slow
+// subscriber delays delivery to the rest. Callers must eventually call
+// Unsubscribe, or do so implicitly by calling Stop.
+func (w *Watchman) Subscribe() (SubID, <-chan Event, <-chan error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.subs == nil {
+		w.subs = make(map[SubID]*subscriber)
+	}
+
+	buf := w.subscriberBuffer
+	if w.slowSubscriberPolicy == DropOldest && buf < 1 {
+		buf = 1
+	}
+
+	w.nextSubID++
+	sub := &subscriber{
+		id:      w.nextSubID,
+		events:  make(chan Event, buf),
+		errs:    make(chan error, buf),
+		closing: make(chan struct{}),
+	}
+	w.subs[sub.id] = sub
+	return sub.id, sub.events, sub.errs
+}
+
+// Unsubscribe removes the subscriber identified by id and closes its
+// channels. It is a no-op if id is not (or is no longer) subscribed.
+//
+// Unsubscribe is safe to call while the poller is concurrently blocked
+// trying to send to this same subscriber: it interrupts the in-flight send
+// via sub.closing before closing sub.events/sub.errs, so the close can
+// never race a send the way it would if the channels were simply closed out
+// from under it.
+func (w *Watchman) Unsubscribe(id SubID) {
+	w.mu.Lock()
+	sub, ok := w.subs[id]
+	if ok {
+		delete(w.subs, id)
+	}
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	sub.signalClosing()
+	sub.mu.Lock()
+	sub.closeChannelsLocked()
+	sub.mu.Unlock()
+}
+
+// snapshotSubs returns the current subscribers without holding w.mu while
+// they're sent to, so a blocked send on one subscriber can't stall
+// Subscribe/Unsubscribe calls from other goroutines.
+func (w *Watchman) snapshotSubs() []*subscriber {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	subs := make([]*subscriber, 0, len(w.subs))
+	for _, sub := range w.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// disconnect removes sub from w.subs and closes its channels. The caller
+// must already hold sub.mu, e.g. because it's the Disconnect branch of a
+// send that's about to give up on sub.
+func (w *Watchman) disconnect(sub *subscriber) {
+	w.mu.Lock()
+	delete(w.subs, sub.id)
+	w.mu.Unlock()
+	sub.closeChannelsLocked()
+}
+
+// emitEvent fans event out to every live subscriber according to the
+// configured SlowSubscriberPolicy.
+func (w *Watchman) emitEvent(event Event) {
+	for _, sub := range w.snapshotSubs() {
+		w.sendEvent(sub, event)
+	}
+}
+
+// sendEvent delivers event to sub according to the configured
+// SlowSubscriberPolicy. It holds sub.mu for the duration so Unsubscribe
+// can't close sub's channels out from under it; Unsubscribe instead signals
+// sub.closing first, which unblocks a pending Block send without needing
+// sub.mu.
+func (w *Watchman) sendEvent(sub *subscriber, event Event) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return
+	}
+
+	switch w.slowSubscriberPolicy {
+	case Disconnect:
+		select {
+		case sub.events <- event:
+		default:
+			w.disconnect(sub)
+		}
+	case DropOldest:
+		for {
+			select {
+			case sub.events <- event:
+				return
+			case <-sub.closing:
+				return
+			default:
+				select {
+				case <-sub.events:
+				default:
+				}
+			}
+		}
+	default: // Block
+		select {
+		case sub.events <- event:
+		case <-sub.closing:
+		}
+	}
+}
+
+// emitErr fans err out to every live subscriber according to the
+// configured SlowSubscriberPolicy.
+func (w *Watchman) emitErr(err error) {
+	for _, sub := range w.snapshotSubs() {
+		w.sendErr(sub, err)
+	}
+}
+
+// sendErr is emitErr's counterpart to sendEvent; see its doc for the
+// locking rationale.
+func (w *Watchman) sendErr(sub *subscriber, err error) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return
+	}
+
+	switch w.slowSubscriberPolicy {
+	case Disconnect:
+		select {
+		case sub.errs <- err:
+		default:
+			w.disconnect(sub)
+		}
+	case DropOldest:
+		for {
+			select {
+			case sub.errs <- err:
+				return
+			case <-sub.closing:
+				return
+			default:
+				select {
+				case <-sub.errs:
+				default:
+				}
+			}
+		}
+	default: // Block
+		select {
+		case sub.errs <- err:
+		case <-sub.closing:
+		}
+	}
+}
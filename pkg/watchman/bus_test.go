@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestUnsubscribeDuringEmitDoesNotPanic reproduces a subscriber being torn
+// down (via Unsubscribe) while the poller is concurrently blocked trying to
+// send it an event, with the default Block policy and an unbuffered
+// subscriber channel. Before the fix this raced emitEvent's send against
+// Unsubscribe's close and panicked with "send on closed channel".
+func TestUnsubscribeDuringEmitDoesNotPanic(t *testing.T) {
+	w := New()
+	id, _, _ := w.Subscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.emitEvent(Event{changes: []Change{{}}})
+	}()
+
+	// Give emitEvent a chance to start blocking on the send before we tear
+	// the subscriber down from underneath it.
+	time.Sleep(10 * time.Millisecond)
+	w.Unsubscribe(id)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("emitEvent never returned after Unsubscribe")
+	}
+}
+
+// TestStopDuringEmitDoesNotPanic is the Stop-based variant of the same race:
+// Stop unsubscribes every subscriber while the poller may still be blocked
+// sending to one of them.
+func TestStopDuringEmitDoesNotPanic(t *testing.T) {
+	w := New()
+	w.stopChan = make(chan struct{})
+	w.ticker = time.NewTicker(time.Hour)
+	w.Subscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.emitEvent(Event{changes: []Change{{}}})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	w.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("emitEvent never returned after Stop")
+	}
+}
+
+// TestDropOldestUnbufferedDoesNotLivelock confirms that DropOldest makes
+// progress even when the subscriber buffer is left at its 0 default and the
+// subscriber never drains, instead of spinning forever waiting for a drain
+// that will never come.
+func TestDropOldestUnbufferedDoesNotLivelock(t *testing.T) {
+	w := New(WithSlowSubscriberPolicy(DropOldest))
+	w.Subscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			w.emitEvent(Event{changes: []Change{{}}})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("emitEvent with DropOldest livelocked on an undrained, unbuffered subscriber")
+	}
+}
+
+// TestDropOldestKeepsMostRecent checks the documented "slow subscriber sees
+// a partial but recent view" behaviour: once the subscriber catches up, the
+// last event it sees should be the most recently emitted one, not a stale
+// one evicted along the way.
+func TestDropOldestKeepsMostRecent(t *testing.T) {
+	w := New(WithSlowSubscriberPolicy(DropOldest), WithSubscriberBuffer(1))
+	_, events, _ := w.Subscribe()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			w.emitEvent(Event{changes: []Change{{Kind: ChangeKind(i)}}})
+		}
+	}()
+	wg.Wait()
+
+	last := <-events
+	if got := last.changes[0].Kind; got != ChangeKind(9) {
+		t.Fatalf("expected the most recent event (kind 9), got kind %d", got)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"bytes"
+	"time"
+)
+
+// pendingChange tracks a file's in-flight debounce window: a change has
+// been observed but is withheld until the file has been stable for a quiet
+// period.
+type pendingChange struct {
+	kind      ChangeKind
+	oldData   FileData
+	data      FileData
+	modTime   time.Time
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// WithDebounce sets the default quiet period a file's content must hold
+// steady for before a Change is emitted, coalescing rapid back-to-back
+// changes (e.g. a hotplug event while the kernel is still populating sysfs)
+// into one. Use WithQuietPeriod to override this per file. The default is 0
+// (no debouncing, a change is emitted on the tick it's observed).
+func WithDebounce(d time.Duration) NewOpt {
+	return func(w *Watchman) error {
+		w.debounce = d
+		return nil
+	}
+}
+
+// WithQuietPeriod overrides the Watchman's default debounce duration for a
+// single file.
+func WithQuietPeriod(d time.Duration) NewFileOpt {
+	return func(f *File) error {
+		f.quietPeriod = d
+		f.hasQuietPeriod = true
+		return nil
+	}
+}
+
+// quietPeriodFor returns the debounce duration that applies to f.
+func (w *Watchman) quietPeriodFor(f *File) time.Duration {
+	if f.hasQuietPeriod {
+		return f.quietPeriod
+	}
+	return w.debounce
+}
+
+// observe folds a freshly observed kind/data pair for f into its debounce
+// window and returns the resulting Change once it has settled, or nil while
+// it's still within its quiet period.
+func (w *Watchman) observe(f *File, kind ChangeKind, data FileData, modTime, now time.Time) *Change {
+	quiet := w.quietPeriodFor(f)
+	if quiet <= 0 {
+		old := f.fileData
+		f.fileData = data
+		return &Change{File: f, Kind: kind, OldData: old, NewData: data, ModTime: modTime, FirstSeen: now, LastSeen: now}
+	}
+
+	if f.pending == nil {
+		f.pending = &pendingChange{
+			kind:      kind,
+			oldData:   f.fileData,
+			data:      data,
+			modTime:   modTime,
+			firstSeen: now,
+			lastSeen:  now,
+		}
+		return nil
+	}
+
+	if f.pending.kind != kind || !bytes.Equal(f.pending.data, data) {
+		f.pending.kind = kind
+		f.pending.data = data
+		f.pending.modTime = modTime
+		f.pending.lastSeen = now
+		return nil
+	}
+
+	return w.checkPending(f, now)
+}
+
+// checkPending finalizes f's pending change once it has been stable for its
+// quiet period, or reports it's still settling.
+func (w *Watchman) checkPending(f *File, now time.Time) *Change {
+	p := f.pending
+	if now.Sub(p.lastSeen) < w.quietPeriodFor(f) {
+		return nil
+	}
+
+	change := &Change{
+		File:      f,
+		Kind:      p.kind,
+		OldData:   p.oldData,
+		NewData:   p.data,
+		ModTime:   p.modTime,
+		FirstSeen: p.firstSeen,
+		LastSeen:  p.lastSeen,
+	}
+	f.fileData = p.data
+	f.pending = nil
+	return change
+}
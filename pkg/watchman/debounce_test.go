@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObserveNoDebounceEmitsImmediately(t *testing.T) {
+	w := New()
+	f := &File{}
+
+	change := w.observe(f, Modified, FileData("v1"), time.Now(), time.Now())
+	if change == nil {
+		t.Fatal("expected an immediate Change with no debounce configured")
+	}
+	if string(f.fileData) != "v1" {
+		t.Fatalf("fileData = %q, want %q", f.fileData, "v1")
+	}
+}
+
+func TestObserveDebounceCoalescesFlapping(t *testing.T) {
+	w := New(WithDebounce(50 * time.Millisecond))
+	f := &File{}
+
+	t0 := time.Now()
+	if change := w.observe(f, Modified, FileData("v1"), t0, t0); change != nil {
+		t.Fatalf("expected nil while still settling, got %+v", change)
+	}
+	if f.pending == nil {
+		t.Fatal("expected a pending change to be recorded")
+	}
+
+	// A different value arriving before the quiet period elapses restarts
+	// the window instead of emitting.
+	t1 := t0.Add(10 * time.Millisecond)
+	if change := w.observe(f, Modified, FileData("v2"), t1, t1); change != nil {
+		t.Fatalf("expected nil for a flapping change, got %+v", change)
+	}
+
+	// The same value seen again after the quiet period has elapsed (since
+	// t1) settles and is emitted.
+	t2 := t1.Add(60 * time.Millisecond)
+	change := w.observe(f, Modified, FileData("v2"), t2, t2)
+	if change == nil {
+		t.Fatal("expected the settled change to be emitted")
+	}
+	if string(change.NewData) != "v2" {
+		t.Fatalf("NewData = %q, want %q", change.NewData, "v2")
+	}
+	if f.pending != nil {
+		t.Fatal("expected pending to be cleared once settled")
+	}
+	if string(f.fileData) != "v2" {
+		t.Fatalf("fileData = %q, want %q", f.fileData, "v2")
+	}
+}
+
+func TestQuietPeriodForOverridesDefault(t *testing.T) {
+	w := New(WithDebounce(time.Minute))
+	f := &File{}
+
+	if got := w.quietPeriodFor(f); got != time.Minute {
+		t.Fatalf("quietPeriodFor = %v, want %v", got, time.Minute)
+	}
+
+	WithQuietPeriod(time.Second)(f)
+	if got := w.quietPeriodFor(f); got != time.Second {
+		t.Fatalf("quietPeriodFor after override = %v, want %v", got, time.Second)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/fs"
+)
+
+// Digest names a hash algorithm a File can be compared by instead of its raw
+// content.
+type Digest interface {
+	// New returns a fresh hash.Hash ready to be written to.
+	New() hash.Hash
+	// Name identifies the algorithm, e.g. "sha256".
+	Name() string
+}
+
+type digest struct {
+	name string
+	new  func() hash.Hash
+}
+
+func (d digest) New() hash.Hash {
+	return d.new()
+}
+
+func (d digest) Name() string {
+	return d.name
+}
+
+var (
+	// MD5Digest is kept for backward compatibility; prefer SHA256Digest or
+	// stronger for new code since MD5 is collision-prone.
+	MD5Digest    Digest = digest{name: "md5", new: md5.New}
+	SHA1Digest   Digest = digest{name: "sha1", new: sha1.New}
+	SHA256Digest Digest = digest{name: "sha256", new: sha256.New}
+	SHA512Digest Digest = digest{name: "sha512", new: sha512.New}
+)
+
+// WithDigest compares the file by streaming its content through d rather
+// than holding the full content in memory, which matters for large device
+// files such as raw block device nodes.
+func WithDigest(d Digest) NewFileOpt {
+	return func(f *File) error {
+		f.reader = DigestReader(d)
+		f.digest = d
+		return nil
+	}
+}
+
+// DigestReader returns a FileReader that streams the file through d and
+// returns the resulting sum.
+func DigestReader(d Digest) FileReader {
+	return func(f fs.File) (FileData, error) {
+		h := d.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err
+		}
+		return h.Sum(nil), nil
+	}
+}
+
+// Checksum returns the digest algorithm name and the hex-encoded sum last
+// observed for f. Files created without WithDigest or WithReader use
+// SHA256Digest. It returns ("", "") if f has no digest, i.e. it was given a
+// custom reader via WithReader and no WithDigest.
+func (f *File) Checksum() (name, hexSum string) {
+	if f.digest == nil {
+		return "", ""
+	}
+	return f.digest.Name(), hex.EncodeToString(f.fileData)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"testing/fstest"
+)
+
+// TestNewFileDefaultsToSHA256 checks that a File created without
+// WithDigest/WithReader compares by SHA256 sum rather than buffering the
+// whole file, matching the package's documented sha256 default.
+func TestNewFileDefaultsToSHA256(t *testing.T) {
+	content := []byte("block device contents")
+	src := fstest.MapFS{
+		"dev/sdz": &fstest.MapFile{Data: content},
+	}
+
+	f, err := NewFileFromSource(src, "dev/sdz")
+	if err != nil {
+		t.Fatalf("NewFileFromSource: %v", err)
+	}
+
+	name, hexSum := f.Checksum()
+	if name != "sha256" {
+		t.Fatalf("expected default digest name %q, got %q", "sha256", name)
+	}
+
+	want := sha256.Sum256(content)
+	if hexSum != hex.EncodeToString(want[:]) {
+		t.Fatalf("expected sha256 sum %x, got %s", want, hexSum)
+	}
+}
@@ -0,0 +1,334 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchConfig holds the options shared by DirWatch and GlobWatch.
+type matchConfig struct {
+	depth          int
+	include        []string
+	exclude        []string
+	followSymlinks bool
+}
+
+// DirWatch tracks a directory whose matching files are (re)discovered on
+// every poll tick.
+type DirWatch struct {
+	path   string
+	cfg    matchConfig
+	known  map[string]*File
+	source Source
+}
+
+// GlobWatch tracks a glob pattern whose matching files are (re)discovered on
+// every poll tick.
+type GlobWatch struct {
+	pattern string
+	cfg     matchConfig
+	known   map[string]*File
+	source  Source
+}
+
+// NewDirOpt configures a DirWatch created via NewDir.
+type NewDirOpt func(*matchConfig)
+
+// NewGlobOpt configures a GlobWatch created via NewGlob.
+type NewGlobOpt func(*matchConfig)
+
+// WithDepth limits how many directory levels below path are descended into.
+// 0 only looks at the given directory, -1 recurses without limit.
+func WithDepth(depth int) func(*matchConfig) {
+	return func(c *matchConfig) {
+		c.depth = depth
+	}
+}
+
+// WithInclude restricts matches to files whose base name matches at least
+// one of the given glob patterns.
+func WithInclude(patterns ...string) func(*matchConfig) {
+	return func(c *matchConfig) {
+		c.include = append(c.include, patterns...)
+	}
+}
+
+// WithExclude skips files whose base name matches any of the given glob
+// patterns, e.g. ".git" or "*.tmp".
+func WithExclude(patterns ...string) func(*matchConfig) {
+	return func(c *matchConfig) {
+		c.exclude = append(c.exclude, patterns...)
+	}
+}
+
+// WithFollowSymlinks controls whether symlinked files are included while
+// expanding a directory or glob pattern. Symlinked directories are never
+// descended into, whatever the setting.
+func WithFollowSymlinks(follow bool) func(*matchConfig) {
+	return func(c *matchConfig) {
+		c.followSymlinks = follow
+	}
+}
+
+// NewDir registers a directory to be polled for matching files. The
+// returned DirWatch must be passed to Watchman.AddDir before it takes
+// effect; path isn't validated until then, since AddDir is what knows
+// which Source it should be resolved against (see WithSource).
+func NewDir(path string, opts ...NewDirOpt) (*DirWatch, error) {
+	d := &DirWatch{
+		path:  path,
+		known: make(map[string]*File),
+	}
+	for _, opt := range opts {
+		opt(&d.cfg)
+	}
+	return d, nil
+}
+
+// NewGlob registers a glob pattern to be polled for matching files. The
+// returned GlobWatch must be passed to Watchman.AddGlob before it takes
+// effect.
+func NewGlob(pattern string, opts ...NewGlobOpt) (*GlobWatch, error) {
+	g := &GlobWatch{
+		pattern: pattern,
+		known:   make(map[string]*File),
+	}
+	for _, opt := range opts {
+		opt(&g.cfg)
+	}
+	return g, nil
+}
+
+// matches reports whether name (a base file name) passes the include/exclude
+// filters in cfg.
+func (c matchConfig) matches(name string) bool {
+	if len(c.include) > 0 {
+		var matched bool
+		for _, pattern := range c.include {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, pattern := range c.exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// expand walks d.path and returns the set of files currently matching its
+// depth/include/exclude/follow-symlinks configuration. It walks through
+// d.source when that was registered via WithSource and implements FSSource,
+// so discovery can target a fake/in-memory filesystem the same way per-file
+// reads already can; otherwise it falls back to os/filepath directly.
+// follow-symlinks has no effect on an FSSource walk, since fs.FS doesn't
+// expose symlink information.
+func (d *DirWatch) expand() ([]string, error) {
+	if fsrc, ok := d.source.(FSSource); ok {
+		return d.expandFS(fsrc.FS())
+	}
+
+	root := filepath.Clean(d.path)
+	var matches []string
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !d.cfg.followSymlinks {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			resolved, statErr := os.Stat(path)
+			if statErr != nil {
+				return nil
+			}
+			info = resolved
+		}
+
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+			if d.cfg.depth >= 0 && dirDepth(root, path) > d.cfg.depth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.cfg.depth >= 0 && dirDepth(root, filepath.Dir(path)) > d.cfg.depth {
+			return nil
+		}
+
+		if !d.cfg.matches(filepath.Base(path)) {
+			return nil
+		}
+		matches = append(matches, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// expandFS is expand's FSSource-backed counterpart, walking fsys with
+// fs.WalkDir instead of filepath.Walk.
+func (d *DirWatch) expandFS(fsys fs.FS) ([]string, error) {
+	root := d.path
+	var matches []string
+
+	err := fs.WalkDir(fsys, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if name == root {
+				return nil
+			}
+			if d.cfg.depth >= 0 && fsDepth(root, name) > d.cfg.depth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.cfg.depth >= 0 && fsDepth(root, path.Dir(name)) > d.cfg.depth {
+			return nil
+		}
+
+		if !d.cfg.matches(path.Base(name)) {
+			return nil
+		}
+		matches = append(matches, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// expand evaluates g.pattern and returns the set of files currently matching
+// its include/exclude/follow-symlinks configuration. It globs through
+// g.source when that was registered via WithSource and implements
+// FSSource, so discovery can target a fake/in-memory filesystem the same
+// way per-file reads already can; otherwise it falls back to os/filepath
+// directly. follow-symlinks has no effect on an FSSource glob, since fs.FS
+// doesn't expose symlink information.
+func (g *GlobWatch) expand() ([]string, error) {
+	if fsrc, ok := g.source.(FSSource); ok {
+		return g.expandFS(fsrc.FS())
+	}
+
+	candidates, err := filepath.Glob(g.pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, path := range candidates {
+		info, statErr := os.Lstat(path)
+		if statErr != nil {
+			continue
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !g.cfg.followSymlinks {
+				continue
+			}
+			resolved, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			info = resolved
+		}
+		if info.IsDir() {
+			continue
+		}
+		if !g.cfg.matches(filepath.Base(path)) {
+			continue
+		}
+		matches = append(matches, path)
+	}
+	return matches, nil
+}
+
+// expandFS is expand's FSSource-backed counterpart, matching fsys against
+// g.pattern with fs.Glob instead of filepath.Glob.
+func (g *GlobWatch) expandFS(fsys fs.FS) ([]string, error) {
+	candidates, err := fs.Glob(fsys, g.pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, name := range candidates {
+		info, statErr := fs.Stat(fsys, name)
+		if statErr != nil {
+			continue
+		}
+		if info.IsDir() {
+			continue
+		}
+		if !g.cfg.matches(path.Base(name)) {
+			continue
+		}
+		matches = append(matches, name)
+	}
+	return matches, nil
+}
+
+// dirDepth returns how many directory levels dir is below root.
+func dirDepth(root, dir string) int {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(os.PathSeparator)) + 1
+}
+
+// fsDepth is dirDepth's fs.FS counterpart: fs.FS paths are always
+// slash-separated regardless of GOOS, so depth is counted against "/"
+// rather than os.PathSeparator.
+func fsDepth(root, dir string) int {
+	if dir == root {
+		return 0
+	}
+	rel := dir
+	if root != "." {
+		rel = strings.TrimPrefix(dir, root+"/")
+		if rel == dir {
+			return 0
+		}
+	}
+	return strings.Count(rel, "/") + 1
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestDirWatchExpandDepthAndFilters(t *testing.T) {
+	root := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "a.conf"), "a")
+	mustWriteFile(t, filepath.Join(root, "b.tmp"), "b")
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	mustWriteFile(t, filepath.Join(root, "sub", "c.conf"), "c")
+
+	d, err := NewDir(root, WithDepth(0), WithExclude("*.tmp"))
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+
+	matches, err := d.expand()
+	if err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	assertPathSet(t, matches, []string{filepath.Join(root, "a.conf")})
+
+	d, err = NewDir(root, WithDepth(-1))
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+	matches, err = d.expand()
+	if err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	assertPathSet(t, matches, []string{
+		filepath.Join(root, "a.conf"),
+		filepath.Join(root, "b.tmp"),
+		filepath.Join(root, "sub", "c.conf"),
+	})
+}
+
+func TestGlobWatchExpandInclude(t *testing.T) {
+	root := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "a.conf"), "a")
+	mustWriteFile(t, filepath.Join(root, "b.conf"), "b")
+	mustWriteFile(t, filepath.Join(root, "c.yaml"), "c")
+
+	g, err := NewGlob(filepath.Join(root, "*"), WithInclude("*.conf"))
+	if err != nil {
+		t.Fatalf("NewGlob: %v", err)
+	}
+
+	matches, err := g.expand()
+	if err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	assertPathSet(t, matches, []string{
+		filepath.Join(root, "a.conf"),
+		filepath.Join(root, "b.conf"),
+	})
+}
+
+func mustWriteFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile(%s): %v", path, err)
+	}
+}
+
+func assertPathSet(t *testing.T, got, want []string) {
+	t.Helper()
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
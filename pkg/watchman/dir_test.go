@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"io/fs"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// mapFSSource adapts an fstest.MapFS into an FSSource for tests: MapFS
+// already satisfies Source (Open/Stat), so only FS needs adding.
+type mapFSSource struct {
+	fstest.MapFS
+}
+
+func (s mapFSSource) FS() fs.FS {
+	return s.MapFS
+}
+
+func TestDirWatchExpandUsesFSSource(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"cfg/a.conf":       &fstest.MapFile{Data: []byte("a")},
+		"cfg/b.conf":       &fstest.MapFile{Data: []byte("b")},
+		"cfg/sub/c.conf":   &fstest.MapFile{Data: []byte("c")},
+		"cfg/ignore.tmp":   &fstest.MapFile{Data: []byte("x")},
+		"elsewhere/d.conf": &fstest.MapFile{Data: []byte("d")},
+	}
+
+	w := New(WithSource(mapFSSource{mapFS}))
+	d, err := NewDir("cfg", WithDepth(0), WithExclude("*.tmp"))
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+	if err := w.AddDir(d); err != nil {
+		t.Fatalf("AddDir: %v", err)
+	}
+
+	matches, err := d.expand()
+	if err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	sort.Strings(matches)
+
+	want := []string{"cfg/a.conf", "cfg/b.conf"}
+	if len(matches) != len(want) {
+		t.Fatalf("expand() = %v, want %v", matches, want)
+	}
+	for i, m := range matches {
+		if m != want[i] {
+			t.Fatalf("expand() = %v, want %v", matches, want)
+		}
+	}
+}
+
+// TestAddDirValidatesAgainstSource checks that NewDir no longer validates
+// path against the real os filesystem (so it can be used to describe a
+// directory that only exists in a custom Source), and that AddDir performs
+// that validation against the Watchman's actual Source instead.
+func TestAddDirValidatesAgainstSource(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"cfg/a.conf": &fstest.MapFile{Data: []byte("a")},
+	}
+
+	d, err := NewDir("cfg")
+	if err != nil {
+		t.Fatalf("NewDir should not touch the real filesystem: %v", err)
+	}
+
+	w := New(WithSource(mapFSSource{mapFS}))
+	if err := w.AddDir(d); err != nil {
+		t.Fatalf("AddDir against a Source where cfg exists: %v", err)
+	}
+
+	missing, err := NewDir("does-not-exist")
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+	if err := w.AddDir(missing); err == nil {
+		t.Fatal("expected AddDir to reject a path missing from the Watchman's Source")
+	}
+}
+
+func TestGlobWatchExpandUsesFSSource(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"cfg/a.conf": &fstest.MapFile{Data: []byte("a")},
+		"cfg/b.conf": &fstest.MapFile{Data: []byte("b")},
+		"cfg/b.tmp":  &fstest.MapFile{Data: []byte("b")},
+	}
+
+	w := New(WithSource(mapFSSource{mapFS}))
+	g, err := NewGlob("cfg/*.conf")
+	if err != nil {
+		t.Fatalf("NewGlob: %v", err)
+	}
+	if err := w.AddGlob(g); err != nil {
+		t.Fatalf("AddGlob: %v", err)
+	}
+
+	matches, err := g.expand()
+	if err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	sort.Strings(matches)
+
+	want := []string{"cfg/a.conf", "cfg/b.conf"}
+	if len(matches) != len(want) {
+		t.Fatalf("expand() = %v, want %v", matches, want)
+	}
+	for i, m := range matches {
+		if m != want[i] {
+			t.Fatalf("expand() = %v, want %v", matches, want)
+		}
+	}
+}
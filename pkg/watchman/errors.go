@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFileVanished is returned (wrapped in a WatchError) when a file that
+// passed its stat check disappears before it can be opened, the common
+// Deleted-during-read race. Callers can drop such errors with
+// errors.Is(err, watchman.ErrFileVanished).
+var ErrFileVanished = errors.New("watchman: file vanished during read")
+
+// WatchError annotates a poll-time failure with the File and operation
+// ("stat", "open" or "read") that produced it, so a consumer reading a
+// subscriber's error channel doesn't have to guess which file a bare os error came from.
+type WatchError struct {
+	File *File
+	Op   string
+	Err  error
+}
+
+func (e *WatchError) Error() string {
+	name := "<unknown>"
+	if e.File != nil {
+		name = e.File.fileName
+	}
+	return fmt.Sprintf("watchman: %s %s: %v", e.Op, name, e.Err)
+}
+
+// Unwrap exposes the underlying error so errors.Is/errors.As see through
+// WatchError, e.g. errors.Is(err, watchman.ErrFileVanished).
+func (e *WatchError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWatchErrorUnwrapAndMessage(t *testing.T) {
+	inner := errors.New("boom")
+	f := &File{fileName: "/tmp/thing"}
+	werr := &WatchError{File: f, Op: "read", Err: inner}
+
+	if !errors.Is(werr, inner) {
+		t.Fatal("errors.Is should see through WatchError to the wrapped error")
+	}
+	msg := werr.Error()
+	if !strings.Contains(msg, "read") || !strings.Contains(msg, "/tmp/thing") || !strings.Contains(msg, "boom") {
+		t.Fatalf("Error() = %q, want it to mention the op, file and wrapped error", msg)
+	}
+}
+
+func TestWatchErrorNilFile(t *testing.T) {
+	werr := &WatchError{Op: "stat", Err: errors.New("boom")}
+	if !strings.Contains(werr.Error(), "<unknown>") {
+		t.Fatalf("Error() = %q, want it to fall back to <unknown> for a nil File", werr.Error())
+	}
+}
+
+func TestFileVanishedDuringRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "watched")
+	mustWriteFile(t, path, "v1")
+
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("NewFile: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	_, err = f.readTagged()
+	var werr *WatchError
+	if !errors.As(err, &werr) {
+		t.Fatalf("expected a *WatchError, got %v (%T)", err, err)
+	}
+	if !errors.Is(err, ErrFileVanished) {
+		t.Fatalf("expected errors.Is(err, ErrFileVanished), got %v", err)
+	}
+	if werr.Op != "open" {
+		t.Fatalf("Op = %q, want %q", werr.Op, "open")
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import "time"
+
+// ChangeKind describes why a Change was emitted for a file.
+type ChangeKind int
+
+const (
+	// Modified indicates the file's content differs from what was last
+	// observed.
+	Modified ChangeKind = iota
+	// Created indicates the file is being observed for the first time, or
+	// has reappeared after being Deleted.
+	Created
+	// Deleted indicates the file could no longer be found on this tick.
+	Deleted
+	// MetadataChanged indicates the file's size or modification time
+	// changed but its content did not, e.g. a chmod or touch.
+	MetadataChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Modified:
+		return "Modified"
+	case Created:
+		return "Created"
+	case Deleted:
+		return "Deleted"
+	case MetadataChanged:
+		return "MetadataChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// Change describes a single file's transition observed on a poll tick. When
+// a quiet period is in effect (see WithDebounce/WithQuietPeriod), FirstSeen
+// and LastSeen mark how long the file was flapping before settling; without
+// one they're equal to the tick that produced the Change.
+type Change struct {
+	File      *File
+	Kind      ChangeKind
+	OldData   FileData
+	NewData   FileData
+	ModTime   time.Time
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
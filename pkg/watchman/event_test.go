@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckChangeKinds(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "watched")
+	mustWriteFile(t, path, "v1")
+
+	w := New()
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("NewFile: %v", err)
+	}
+
+	if change, err := w.checkChange(f); err != nil || change != nil {
+		t.Fatalf("checkChange on an unchanged file: change=%v err=%v", change, err)
+	}
+
+	mustWriteFile(t, path, "v2 is longer")
+	change, err := w.checkChange(f)
+	if err != nil {
+		t.Fatalf("checkChange after write: %v", err)
+	}
+	if change == nil || change.Kind != Modified {
+		t.Fatalf("expected Modified, got %+v", change)
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(path, now, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	change, err = w.checkChange(f)
+	if err != nil {
+		t.Fatalf("checkChange after chtimes: %v", err)
+	}
+	if change == nil || change.Kind != MetadataChanged {
+		t.Fatalf("expected MetadataChanged, got %+v", change)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	change, err = w.checkChange(f)
+	if err != nil {
+		t.Fatalf("checkChange after remove: %v", err)
+	}
+	if change == nil || change.Kind != Deleted {
+		t.Fatalf("expected Deleted, got %+v", change)
+	}
+
+	mustWriteFile(t, path, "v3")
+	change, err = w.checkChange(f)
+	if err != nil {
+		t.Fatalf("checkChange after recreate: %v", err)
+	}
+	if change == nil || change.Kind != Created {
+		t.Fatalf("expected Created, got %+v", change)
+	}
+}
+
+func TestChangeKindString(t *testing.T) {
+	cases := map[ChangeKind]string{
+		Modified:         "Modified",
+		Created:          "Created",
+		Deleted:          "Deleted",
+		MetadataChanged:  "MetadataChanged",
+		ChangeKind(1000): "Unknown",
+	}
+	for kind, want := range cases {
+		if got := kind.String(); got != want {
+			t.Errorf("ChangeKind(%d).String() = %q, want %q", kind, got, want)
+		}
+	}
+}
+
+func TestEventFilesAndChanges(t *testing.T) {
+	f1 := &File{fileName: "one"}
+	f2 := &File{fileName: "two"}
+	event := Event{changes: []Change{
+		{File: f1, Kind: Created},
+		{File: f2, Kind: Modified},
+	}}
+
+	files := event.Files()
+	if len(files) != 2 || files[0] != f1 || files[1] != f2 {
+		t.Fatalf("Files() = %v, want [f1, f2]", files)
+	}
+
+	changes := event.Changes()
+	if len(changes) != 2 || changes[0].Kind != Created || changes[1].Kind != Modified {
+		t.Fatalf("Changes() = %+v", changes)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The OpenEBS Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watchman
+
+import (
+	"io/fs"
+	"os"
+)
+
+// Source abstracts the filesystem a File is read from, so tests and
+// non-os filesystems (/proc-emulating fakes, in-memory stubs, sysfs
+// snapshots mounted via a custom fs.FS) don't require touching the real
+// filesystem.
+type Source interface {
+	Open(name string) (fs.File, error)
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// OSSource is the default Source, backed directly by the os package.
+type OSSource struct{}
+
+func (OSSource) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (OSSource) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// WithSource sets the default Source used for files discovered through a
+// DirWatch or GlobWatch. It has no effect on files created directly with
+// NewFileFromSource, which carry their own Source.
+func WithSource(src Source) NewOpt {
+	return func(w *Watchman) error {
+		w.source = src
+		return nil
+	}
+}
+
+// FSSource is implemented by a Source that can additionally list its
+// directory tree, so a DirWatch or GlobWatch can discover files through it
+// instead of walking the real filesystem with os/filepath. A Source that
+// only backs individual file reads doesn't need to implement it; when the
+// Watchman's source doesn't, a DirWatch or GlobWatch discovers files on the
+// real filesystem with filepath.Walk/filepath.Glob.
+type FSSource interface {
+	Source
+	// FS returns the fs.FS that backs directory/glob discovery. Paths
+	// passed to NewDir/NewGlob are looked up in it using fs.FS conventions
+	// (slash-separated, no leading slash), the same as the fake/in-memory
+	// filesystems Source itself targets.
+	FS() fs.FS
+}
+
+// src returns f's Source, defaulting to OSSource when none was set.
+func (f *File) src() Source {
+	if f.source != nil {
+		return f.source
+	}
+	return OSSource{}
+}
+
+// OSFileReader adapts a legacy FileReader written against *os.File so it
+// can still be passed to WithReader. It fails with ErrInvalidFile if the
+// File isn't backed by an OSSource.
+func OSFileReader(legacy func(*os.File) (FileData, error)) FileReader {
+	return func(f fs.File) (FileData, error) {
+		osFile, ok := f.(*os.File)
+		if !ok {
+			return nil, ErrInvalidFile
+		}
+		return legacy(osFile)
+	}
+}
@@ -20,31 +20,64 @@ import (
 	"bytes"
 	"crypto/md5"
 	"errors"
-	"io/ioutil"
+	"io"
+	"io/fs"
 	"os"
+	"sync"
 	"time"
 )
 
 type WatchType int
 type FileData []byte
-type FileReader func(file *os.File) (FileData, error)
+type FileReader func(file fs.File) (FileData, error)
 type File struct {
 	fileName string
 	fileData FileData
 	reader   FileReader
 	tag      string
+	digest   Digest
+	source   Source
+
+	// exists, modTime and size cache the last observed os.FileInfo so a
+	// poll tick can skip re-reading a file whose size and mtime haven't
+	// moved, and so a vanished file can be reported as Deleted instead of
+	// surfacing a bare os.Open error.
+	exists  bool
+	modTime time.Time
+	size    int64
+
+	// quietPeriod overrides Watchman.debounce for this file when
+	// hasQuietPeriod is set; pending holds an in-flight change still
+	// waiting to be stable for that long.
+	quietPeriod    time.Duration
+	hasQuietPeriod bool
+	pending        *pendingChange
 }
 
 type Event struct {
-	files []*File
+	changes []Change
 }
 type Watchman struct {
 	files        []*File
+	dirs         []*DirWatch
+	globs        []*GlobWatch
 	ticker       *time.Ticker
 	pollInterval time.Duration
-	eventChan    chan Event
-	errChan      chan error
+	debounce     time.Duration
+	source       Source
 	stopChan     chan struct{}
+
+	mu                   sync.Mutex
+	subs                 map[SubID]*subscriber
+	nextSubID            SubID
+	subscriberBuffer     int
+	slowSubscriberPolicy SlowSubscriberPolicy
+
+	// legacySub backs the single-consumer Start/Events/Err API with a
+	// regular subscriber on the bus.
+	legacySub   SubID
+	legacyEvent <-chan Event
+	legacyErr   <-chan error
 }
 
 type NewOpt func(w *Watchman) error
@@ -70,15 +103,21 @@ func New(opts ...NewOpt) *Watchman {
 	return &w
 }
 
+// NewFile registers a single file to be polled, reading it through the
+// default OSSource.
 func NewFile(fileName string, opts ...NewFileOpt) (*File, error) {
+	return NewFileFromSource(OSSource{}, fileName, opts...)
+}
+
+// NewFileFromSource registers a single file to be polled through src
+// instead of the real filesystem.
+func NewFileFromSource(src Source, fileName string, opts ...NewFileOpt) (*File, error) {
 	var err error
-	f := File{}
+	f := File{fileName: fileName, source: src}
 
-	_, err = os.Stat(fileName)
-	if err != nil {
+	if err := f.stat(); err != nil {
 		return nil, err
 	}
-	f.fileName = fileName
 
 	for _, opt := range opts {
 		err = opt(&f)
@@ -89,21 +128,113 @@ func NewFile(fileName string, opts ...NewFileOpt) (*File, error) {
 
 	// set defaults
 	if f.reader == nil {
-		f.reader = ReadFile
+		f.reader = DigestReader(SHA256Digest)
+		f.digest = SHA256Digest
 	}
 
 	// read initial file data
-	openFile, err := os.Open(f.fileName)
+	data, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	f.fileData = data
+
+	return &f, nil
+}
+
+// read opens the underlying file through f.src() and runs it through
+// f.reader.
+func (f *File) read() (FileData, error) {
+	openFile, err := f.src().Open(f.fileName)
 	if err != nil {
 		return nil, err
 	}
+	defer openFile.Close()
+	return f.reader(openFile)
+}
+
+// readTagged is read, with every failure wrapped in a WatchError that names
+// the operation that failed so a poll-loop consumer doesn't have to guess
+// from a bare os error. A file that disappears between the stat and the
+// open that follows it is reported as ErrFileVanished rather than a raw
+// "no such file" from the open call.
+func (f *File) readTagged() (FileData, error) {
+	openFile, err := f.src().Open(f.fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &WatchError{File: f, Op: "open", Err: ErrFileVanished}
+		}
+		return nil, &WatchError{File: f, Op: "open", Err: err}
+	}
+	defer openFile.Close()
+
 	data, err := f.reader(openFile)
+	if err != nil {
+		return nil, &WatchError{File: f, Op: "read", Err: err}
+	}
+	return data, nil
+}
+
+// stat refreshes f's cached size/mtime/exists from f.src().
+func (f *File) stat() error {
+	info, err := f.src().Stat(f.fileName)
+	if err != nil {
+		return err
+	}
+	f.exists = true
+	f.size = info.Size()
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// checkChange stats f and, if it has been created, modified, had its
+// metadata change, or vanished since the last tick, returns the Change
+// describing the transition. Under a quiet period (WithDebounce or
+// WithQuietPeriod) the Change is returned only once it has settled, and
+// nil, nil is returned while it is still pending. It returns nil, nil when
+// nothing has changed, including a file that was already known to be
+// missing.
+func (w *Watchman) checkChange(f *File) (*Change, error) {
+	now := time.Now()
+
+	info, err := f.src().Stat(f.fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			if !f.exists && f.pending == nil {
+				return nil, nil
+			}
+			modTime := f.modTime
+			f.exists = false
+			return w.observe(f, Deleted, nil, modTime, now), nil
+		}
+		return nil, &WatchError{File: f, Op: "stat", Err: err}
+	}
+
+	if f.exists && info.Size() == f.size && info.ModTime().Equal(f.modTime) {
+		if f.pending == nil {
+			return nil, nil
+		}
+		return w.checkPending(f, now), nil
+	}
+
+	data, err := f.readTagged()
 	if err != nil {
 		return nil, err
 	}
-	f.fileData = data
 
-	return &f, nil
+	wasMissing := !f.exists
+	f.exists = true
+	f.size = info.Size()
+	f.modTime = info.ModTime()
+
+	kind := MetadataChanged
+	switch {
+	case wasMissing:
+		kind = Created
+	case !bytes.Equal(f.fileData, data):
+		kind = Modified
+	}
+	return w.observe(f, kind, data, f.modTime, now), nil
 }
 
 func WithPollInterval(duration time.Duration) NewOpt {
@@ -130,11 +261,14 @@ func WithTag(tag string) NewFileOpt {
 	}
 }
 
+// Start begins polling and returns a single subscriber's event and error
+// channels. It is a thin convenience wrapper around Subscribe for callers
+// that only need one consumer; use Subscribe directly to register more than
+// one.
 func (w *Watchman) Start() (<-chan Event, <-chan error) {
 	w.ticker = time.NewTicker(w.pollInterval)
-	w.eventChan = make(chan Event)
-	w.errChan = make(chan error)
 	w.stopChan = make(chan struct{})
+	w.legacySub, w.legacyEvent, w.legacyErr = w.Subscribe()
 	go func() {
 		for {
 			select {
@@ -145,37 +279,110 @@ func (w *Watchman) Start() (<-chan Event, <-chan error) {
 			}
 		}
 	}()
-	return w.eventChan, w.errChan
+	return w.legacyEvent, w.legacyErr
 }
 
+// Stop halts polling and unsubscribes every subscriber, closing their
+// channels exactly once.
 func (w *Watchman) Stop() {
 	close(w.stopChan)
-	close(w.errChan)
-	close(w.eventChan)
 	w.ticker.Stop()
+
+	w.mu.Lock()
+	ids := make([]SubID, 0, len(w.subs))
+	for id := range w.subs {
+		ids = append(ids, id)
+	}
+	w.mu.Unlock()
+
+	for _, id := range ids {
+		w.Unsubscribe(id)
+	}
 }
 
 func (w *Watchman) publishChanges() {
 	event := Event{}
 	for _, f := range w.files {
-		openFile, err := os.Open(f.fileName)
+		change, err := w.checkChange(f)
 		if err != nil {
-			w.errChan <- err
+			w.emitErr(err)
 			continue
 		}
+		if change != nil {
+			event.changes = append(event.changes, *change)
+		}
+	}
 
-		data, err := f.reader(openFile)
+	for _, d := range w.dirs {
+		w.publishDirChanges(d, &event)
+	}
+	for _, g := range w.globs {
+		w.publishGlobChanges(g, &event)
+	}
+
+	if len(event.changes) > 0 {
+		w.emitEvent(event)
+	}
+}
+
+// publishDirChanges expands d and diffs the result against d.known,
+// appending changed and newly discovered files to event.
+func (w *Watchman) publishDirChanges(d *DirWatch, event *Event) {
+	matched, err := d.expand()
+	if err != nil {
+		w.emitErr(err)
+		return
+	}
+	w.reconcileKnown(d.known, matched, event)
+}
+
+// publishGlobChanges expands g and diffs the result against g.known,
+// appending changed and newly discovered files to event.
+func (w *Watchman) publishGlobChanges(g *GlobWatch, event *Event) {
+	matched, err := g.expand()
+	if err != nil {
+		w.emitErr(err)
+		return
+	}
+	w.reconcileKnown(g.known, matched, event)
+}
+
+// reconcileKnown brings known up to date with the currently matched paths,
+// emitting a Change for every new, modified or removed file.
+func (w *Watchman) reconcileKnown(known map[string]*File, matched []string, event *Event) {
+	seen := make(map[string]bool, len(matched))
+	for _, path := range matched {
+		seen[path] = true
+
+		f, ok := known[path]
+		if !ok {
+			f = &File{fileName: path, reader: DigestReader(SHA256Digest), digest: SHA256Digest, source: w.source}
+			known[path] = f
+		}
+
+		change, err := w.checkChange(f)
 		if err != nil {
-			w.errChan <- err
-		} else if !bytes.Equal(data, f.fileData) {
-			event.files = append(event.files, f)
-			f.fileData = data
+			w.emitErr(err)
+			continue
+		}
+		if change != nil {
+			event.changes = append(event.changes, *change)
 		}
-		openFile.Close()
 	}
 
-	if len(event.files) > 0 {
-		w.eventChan <- event
+	now := time.Now()
+	for path, f := range known {
+		if !seen[path] {
+			event.changes = append(event.changes, Change{
+				File:      f,
+				Kind:      Deleted,
+				OldData:   f.fileData,
+				ModTime:   f.modTime,
+				FirstSeen: now,
+				LastSeen:  now,
+			})
+			delete(known, path)
+		}
 	}
 }
 
@@ -194,12 +401,71 @@ func (w *Watchman) RemoveFile(file *File) {
 	}
 }
 
+// AddDir validates that d.path exists and is a directory, then registers d
+// so its matching files are polled on every tick. The validation and d's
+// subsequent discovery both go through w's Source (WithSource), defaulting
+// to OSSource, so a DirWatch built from NewDir can still target a fake or
+// in-memory filesystem as long as WithSource is set before AddDir is
+// called.
+func (w *Watchman) AddDir(d *DirWatch) error {
+	src := w.source
+	if src == nil {
+		src = OSSource{}
+	}
+
+	info, err := src.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return ErrInvalidFile
+	}
+
+	d.source = src
+	w.dirs = append(w.dirs, d)
+	return nil
+}
+
+// RemoveDir stops polling d.
+func (w *Watchman) RemoveDir(d *DirWatch) {
+	for idx, existing := range w.dirs {
+		if existing == d {
+			w.dirs[idx] = w.dirs[len(w.dirs)-1]
+			w.dirs = w.dirs[:len(w.dirs)-1]
+			return
+		}
+	}
+}
+
+// AddGlob registers a GlobWatch so its matching files are polled on every
+// tick. g discovers files through w's Source, the same as the files it
+// discovers, so WithSource must be set before AddGlob if g should target a
+// non-os filesystem.
+func (w *Watchman) AddGlob(g *GlobWatch) error {
+	g.source = w.source
+	w.globs = append(w.globs, g)
+	return nil
+}
+
+// RemoveGlob stops polling g.
+func (w *Watchman) RemoveGlob(g *GlobWatch) {
+	for idx, existing := range w.globs {
+		if existing == g {
+			w.globs[idx] = w.globs[len(w.globs)-1]
+			w.globs = w.globs[:len(w.globs)-1]
+			return
+		}
+	}
+}
+
+// Events returns the event channel handed back by Start.
 func (w *Watchman) Events() <-chan Event {
-	return w.eventChan
+	return w.legacyEvent
 }
 
+// Err returns the error channel handed back by Start.
 func (w *Watchman) Err() <-chan error {
-	return w.errChan
+	return w.legacyErr
 }
 
 func (w *Watchman) Find(filter FileFilter) []*File {
@@ -212,8 +478,8 @@ func (w *Watchman) Find(filter FileFilter) []*File {
 	return ret
 }
 
-func MD5Checksum(f *os.File) (FileData, error) {
-	data, err := ioutil.ReadAll(f)
+func MD5Checksum(f fs.File) (FileData, error) {
+	data, err := io.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
@@ -221,8 +487,8 @@ func MD5Checksum(f *os.File) (FileData, error) {
 	return checksum[:], nil
 }
 
-func ReadFile(f *os.File) (FileData, error) {
-	data, err := ioutil.ReadAll(f)
+func ReadFile(f fs.File) (FileData, error) {
+	data, err := io.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
@@ -237,6 +503,18 @@ func (f *File) GetTag() string {
 	return f.tag
 }
 
+// Files returns the set of files touched by this Event, kept for backward
+// compatibility with callers that only care which files changed.
 func (e Event) Files() []*File {
-	return e.files
+	files := make([]*File, 0, len(e.changes))
+	for _, c := range e.changes {
+		files = append(files, c.File)
+	}
+	return files
+}
+
+// Changes returns the per-file Created/Modified/Deleted/MetadataChanged
+// records that make up this Event.
+func (e Event) Changes() []Change {
+	return e.changes
 }